@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// apiError is returned for non-2xx Jira API responses so callers such as
+// the Reconciler can decide whether a failure is worth retrying.
+type apiError struct {
+	uri        string
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("Jira api request to %s failed with status %d", e.uri, e.StatusCode)
+}
+
+// Retryable reports whether the request is worth retrying: rate limits
+// and server-side errors are, client errors aren't.
+func (e *apiError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+func newAPIError(uri string, response *http.Response) *apiError {
+	return &apiError{
+		uri:        uri,
+		StatusCode: response.StatusCode,
+		RetryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+	}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when)
+	}
+
+	return 0
+}
+
+// AuthMode selects how a Client authenticates its requests against the
+// Jira API.
+type AuthMode int
+
+const (
+	// AuthModeSession logs in with a username/password and reuses the
+	// returned session cookie on every subsequent request. This is the
+	// only mode supported by on-prem Jira Server/Data Center installs
+	// that don't expose a token endpoint.
+	AuthModeSession AuthMode = iota
+	// AuthModeBasic sends the username/password as an HTTP Basic
+	// Authorization header on every request.
+	AuthModeBasic
+	// AuthModeBearer sends a Personal Access Token / OAuth token as a
+	// Bearer Authorization header. Required for Jira Cloud.
+	AuthModeBearer
+)
+
+const defaultClientTimeout = 30 * time.Second
+
+// ClientConfig configures a Client.
+type ClientConfig struct {
+	BaseURI  string
+	LoginURI string
+	AuthMode AuthMode
+
+	Username string
+	Password string
+	Token    string
+
+	// Timeout bounds every request issued by the Client when the caller
+	// doesn't already supply a deadline via context. Defaults to
+	// defaultClientTimeout when zero.
+	Timeout time.Duration
+	// Transport lets callers inject a fake round tripper in tests
+	// instead of hitting the network.
+	Transport http.RoundTripper
+}
+
+// Client is a typed Jira REST API client. It supports session, Basic and
+// bearer token authentication and every method takes a context so
+// callers can bound requests with a deadline, the same way the gonet
+// deadlineTimer cancels an in-flight dial or read.
+type Client struct {
+	config     ClientConfig
+	httpClient *http.Client
+
+	sessionMu sync.RWMutex
+	session   session
+}
+
+// NewClient builds a Client from config, applying a default timeout when
+// one isn't set.
+func NewClient(config ClientConfig) *Client {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultClientTimeout
+	}
+
+	return &Client{
+		config: config,
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: config.Transport,
+		},
+	}
+}
+
+// Get performs an authenticated GET request against uri.
+func (c *Client) Get(ctx context.Context, uri string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Jira api request building failed %s", uri)
+	}
+
+	return c.do(request)
+}
+
+// Post performs an authenticated POST request against uri with data sent
+// as the request body.
+func (c *Client) Post(ctx context.Context, uri string, data []byte) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "POST", uri, bytes.NewBuffer(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Jira api request building failed %s", uri)
+	}
+
+	return c.do(request)
+}
+
+// Delete performs an authenticated DELETE request against uri.
+func (c *Client) Delete(ctx context.Context, uri string) error {
+	request, err := http.NewRequestWithContext(ctx, "DELETE", uri, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Jira api request building failed %s", uri)
+	}
+
+	_, err = c.do(request)
+
+	return err
+}
+
+func (c *Client) do(request *http.Request) ([]byte, error) {
+	if err := c.authenticate(request); err != nil {
+		return nil, err
+	}
+
+	request.Header.Add("X-Atlassian-Token", "no-check")
+
+	start := time.Now()
+	response, err := c.httpClient.Do(request)
+	status := "error"
+	if response != nil {
+		status = strconv.Itoa(response.StatusCode)
+	}
+	jiraAPIRequestsTotal.WithLabelValues(request.Method, status).Inc()
+	jiraAPIRequestDuration.WithLabelValues(request.Method, status).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "Jira api request failed %s", request.URL)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Jira api request failed %s", request.URL)
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, newAPIError(request.URL.String(), response)
+	}
+
+	return body, nil
+}
+
+func (c *Client) authenticate(request *http.Request) error {
+	switch c.config.AuthMode {
+	case AuthModeBasic:
+		request.SetBasicAuth(c.config.Username, c.config.Password)
+		return nil
+	case AuthModeBearer:
+		request.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.config.Token))
+		return nil
+	default:
+		loggedInSession, err := c.currentSession(request.Context())
+		if err != nil {
+			return err
+		}
+		request.Header.Add("Cookie", fmt.Sprintf("%s=%s", loggedInSession.Name, loggedInSession.Value))
+		return nil
+	}
+}
+
+// currentSession returns the cached session cookie, logging in to
+// populate it if this is the first authenticated request. The read,
+// check and write are all done under sessionMu so concurrent requests
+// from the webhook server don't race on the cached session.
+func (c *Client) currentSession(ctx context.Context) (session, error) {
+	c.sessionMu.RLock()
+	cached := c.session
+	c.sessionMu.RUnlock()
+
+	if cached.Value != "" {
+		return cached, nil
+	}
+
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.session.Value != "" {
+		return c.session, nil
+	}
+
+	loggedInSession, err := c.login(ctx)
+	if err != nil {
+		return session{}, err
+	}
+
+	c.session = loggedInSession
+
+	return c.session, nil
+}
+
+// login authenticates with username/password and caches the returned
+// session cookie on the Client for subsequent requests.
+func (c *Client) login(ctx context.Context) (session, error) {
+	loginData := map[string]string{"username": c.config.Username, "password": c.config.Password}
+
+	body, err := json.Marshal(loginData)
+	if err != nil {
+		return session{}, errors.Wrap(err, "Auth failed")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", c.config.LoginURI, bytes.NewBuffer(body))
+	if err != nil {
+		return session{}, errors.Wrap(err, "Auth failed")
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return session{}, errors.Wrap(err, "Auth failed")
+	}
+	defer response.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return session{}, errors.Wrap(err, "Auth failed")
+	}
+
+	result := authData{}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return session{}, errors.Wrap(err, "Auth failed")
+	}
+
+	return result.Session, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeTransport is an http.RoundTripper that lets tests respond to
+// requests without hitting the network, per ClientConfig.Transport.
+type fakeTransport struct {
+	mu        sync.Mutex
+	responses []func(*http.Request) (*http.Response, error)
+	requests  []*http.Request
+}
+
+func (t *fakeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.requests = append(t.requests, request)
+
+	respond := t.responses[0]
+	if len(t.responses) > 1 {
+		t.responses = t.responses[1:]
+	}
+
+	return respond(request)
+}
+
+func jsonResponse(status int, body string) func(*http.Request) (*http.Response, error) {
+	return func(request *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: status,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Header:     http.Header{},
+			Request:    request,
+		}, nil
+	}
+}
+
+func TestClientAuthModeBasicSetsBasicAuthHeader(t *testing.T) {
+	transport := &fakeTransport{responses: []func(*http.Request) (*http.Response, error){
+		jsonResponse(http.StatusOK, `{}`),
+	}}
+
+	client := NewClient(ClientConfig{
+		BaseURI:   "http://jira.example",
+		AuthMode:  AuthModeBasic,
+		Username:  "alice",
+		Password:  "secret",
+		Transport: transport,
+	})
+
+	if _, err := client.Get(context.Background(), "http://jira.example/rest/foo"); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+
+	username, password, ok := transport.requests[0].BasicAuth()
+	if !ok || username != "alice" || password != "secret" {
+		t.Fatalf("expected basic auth alice:secret, got %q:%q (ok=%v)", username, password, ok)
+	}
+}
+
+func TestClientAuthModeBearerSetsAuthorizationHeader(t *testing.T) {
+	transport := &fakeTransport{responses: []func(*http.Request) (*http.Response, error){
+		jsonResponse(http.StatusOK, `{}`),
+	}}
+
+	client := NewClient(ClientConfig{
+		BaseURI:   "http://jira.example",
+		AuthMode:  AuthModeBearer,
+		Token:     "tok-123",
+		Transport: transport,
+	})
+
+	if _, err := client.Get(context.Background(), "http://jira.example/rest/foo"); err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+
+	got := transport.requests[0].Header.Get("Authorization")
+	if got != "Bearer tok-123" {
+		t.Fatalf("expected Authorization %q, got %q", "Bearer tok-123", got)
+	}
+}
+
+func TestClientAuthModeSessionLogsInOnceAndReusesCookie(t *testing.T) {
+	transport := &fakeTransport{responses: []func(*http.Request) (*http.Response, error){
+		jsonResponse(http.StatusOK, `{"session":{"name":"JSESSIONID","value":"abc123"}}`),
+		jsonResponse(http.StatusOK, `{}`),
+		jsonResponse(http.StatusOK, `{}`),
+	}}
+
+	client := NewClient(ClientConfig{
+		BaseURI:   "http://jira.example",
+		LoginURI:  "http://jira.example/login",
+		AuthMode:  AuthModeSession,
+		Username:  "alice",
+		Password:  "secret",
+		Transport: transport,
+	})
+
+	if _, err := client.Get(context.Background(), "http://jira.example/rest/foo"); err != nil {
+		t.Fatalf("first Get returned error: %v", err)
+	}
+	if _, err := client.Get(context.Background(), "http://jira.example/rest/bar"); err != nil {
+		t.Fatalf("second Get returned error: %v", err)
+	}
+
+	if len(transport.requests) != 3 {
+		t.Fatalf("expected 1 login + 2 api requests, got %d requests", len(transport.requests))
+	}
+
+	for _, request := range transport.requests[1:] {
+		if got := request.Header.Get("Cookie"); got != "JSESSIONID=abc123" {
+			t.Fatalf("expected cached session cookie on every api request, got %q", got)
+		}
+	}
+}
+
+func TestClientSessionCacheIsRaceFreeUnderConcurrentRequests(t *testing.T) {
+	transport := &fakeTransport{responses: []func(*http.Request) (*http.Response, error){
+		jsonResponse(http.StatusOK, `{"session":{"name":"JSESSIONID","value":"abc123"}}`),
+	}}
+	for i := 0; i < 20; i++ {
+		transport.responses = append(transport.responses, jsonResponse(http.StatusOK, `{}`))
+	}
+
+	client := NewClient(ClientConfig{
+		BaseURI:   "http://jira.example",
+		LoginURI:  "http://jira.example/login",
+		AuthMode:  AuthModeSession,
+		Username:  "alice",
+		Password:  "secret",
+		Transport: transport,
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Get(context.Background(), "http://jira.example/rest/foo"); err != nil {
+				t.Errorf("concurrent Get returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClientReturnsAPIErrorForNon2xxResponse(t *testing.T) {
+	transport := &fakeTransport{responses: []func(*http.Request) (*http.Response, error){
+		jsonResponse(http.StatusServiceUnavailable, `{}`),
+	}}
+
+	client := NewClient(ClientConfig{
+		BaseURI:   "http://jira.example",
+		AuthMode:  AuthModeBearer,
+		Token:     "tok-123",
+		Transport: transport,
+	})
+
+	_, err := client.Get(context.Background(), "http://jira.example/rest/foo")
+	if err == nil {
+		t.Fatal("expected an error for a 503 response")
+	}
+
+	apiErr, ok := err.(*apiError)
+	if !ok {
+		t.Fatalf("expected *apiError, got %T: %v", err, err)
+	}
+	if !apiErr.Retryable() {
+		t.Fatalf("expected 503 to be retryable")
+	}
+}
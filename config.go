@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// config is the on-disk configuration for the webhook server: where to
+// listen, how to reach Jira, and the label routing rules that replace
+// the old hardcoded team constants.
+type config struct {
+	ListenAddr   string `yaml:"listen_addr"`
+	WebhookPath  string `yaml:"webhook_path"`
+	SharedSecret string `yaml:"shared_secret"`
+	// AllowUnsignedWebhooks must be explicitly set to run with
+	// shared_secret empty. Defaults to false so a forgotten
+	// shared_secret fails startup instead of serving an unauthenticated
+	// webhook.
+	AllowUnsignedWebhooks bool `yaml:"allow_unsigned_webhooks"`
+
+	JiraBaseURI  string `yaml:"jira_base_uri"`
+	JiraLoginURI string `yaml:"jira_login_uri"`
+	JiraAuthMode string `yaml:"jira_auth_mode"`
+	JiraUsername string `yaml:"jira_username"`
+	JiraPassword string `yaml:"jira_password"`
+	JiraToken    string `yaml:"jira_token"`
+
+	Routes []RouteConfig `yaml:"routes"`
+}
+
+// loadConfig reads and parses the YAML config file at path.
+func loadConfig(path string) (config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config{}, errors.Wrapf(err, "Can not read config %s", path)
+	}
+
+	cfg := config{}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return config{}, errors.Wrapf(err, "Can not parse config %s", path)
+	}
+
+	return cfg, nil
+}
+
+// authMode maps the config's jira_auth_mode string onto an AuthMode,
+// defaulting to session auth.
+func (c config) authMode() AuthMode {
+	switch c.JiraAuthMode {
+	case "basic":
+		return AuthModeBasic
+	case "bearer":
+		return AuthModeBearer
+	default:
+		return AuthModeSession
+	}
+}
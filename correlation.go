@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type contextKey string
+
+const correlationIDContextKey contextKey = "correlationID"
+
+// withCorrelationID attaches correlationID to ctx so downstream logging
+// and metrics can tie a Jira API call back to the webhook delivery that
+// triggered it.
+func withCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDContextKey, correlationID)
+}
+
+func correlationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDContextKey).(string)
+	return id
+}
+
+// newCorrelationID generates a random correlation ID for deliveries that
+// don't already carry one.
+func newCorrelationID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(buf)
+}
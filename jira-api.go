@@ -1,11 +1,9 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"regexp"
 	"strings"
 
@@ -22,10 +20,6 @@ const createAction string = "create"
 
 const removeAction string = "remove"
 
-const recyclingTeamLabel string = "recycling-nsk"
-
-const recyclingTeamDashboardID int = 351
-
 type session struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
@@ -60,13 +54,6 @@ type issue struct {
 	Fields []field `json:"fields"`
 }
 
-type swimlaneUpdates struct {
-	ID     int
-	Name   string
-	Action string
-	Query  string
-}
-
 type changelog struct {
 	Dataset changelogItems `json:"changelog"`
 }
@@ -81,91 +68,94 @@ type changelogItem struct {
 	Field      string `json:"field"`
 }
 
-func updateDashboardIfNeed(newIssue issue, changelog changelog) error {
-	oldLabels, newLabels := getLabelsFromChangelog(changelog.Dataset.Items)
-	dashboardID := getDashboardID(newLabels)
+// updateDashboardIfNeed resolves newIssue's current labels and reconciles
+// every route they match. Labels normally come from the webhook's
+// changelog (so only issues whose labels actually changed trigger work),
+// but a jira:issue_created event has no changelog at all - newIssue
+// already carries its labels at creation time, so those are read
+// straight off newIssue.Fields instead.
+func updateDashboardIfNeed(ctx context.Context, client *Client, router *Router, newIssue issue, changelog changelog) error {
+	newLabels := labelsForReconciliation(newIssue, changelog)
+	correlationID := correlationIDFromContext(ctx)
+	routes := router.Match(newLabels)
+
+	logger.Info().
+		Str("correlation_id", correlationID).
+		Str("issue_key", newIssue.Key).
+		Strs("labels", newLabels).
+		Int("route_count", len(routes)).
+		Msg("resolved swimlane routes")
+
+	var firstErr error
+	for _, route := range routes {
+		if err := applyRoute(ctx, client, route, newIssue, newLabels); err != nil {
+			logger.Error().
+				Str("correlation_id", correlationID).
+				Str("issue_key", newIssue.Key).
+				Int("dashboard_id", route.DashboardID).
+				Err(err).
+				Msg("swimlane route update failed")
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
 
-	updates, err := getSwimlaneUpdates(dashboardID, newIssue, oldLabels, newLabels)
+func applyRoute(ctx context.Context, client *Client, route Route, newIssue issue, newLabels []string) error {
+	reconciler := NewReconciler(client, route.DryRun)
 
+	ops, err := reconciler.Plan(ctx, route, newIssue, newLabels)
 	if err != nil {
+		recordSwimlaneOperationError("fetch_swimlanes")
 		return err
 	}
 
-	if updates.Action == removeAction {
-		return removeSwimlane(dashboardID, updates.ID)
-	}
-
-	if updates.Action == createAction {
-		createSwimlane(dashboardID, updates)
-	}
-
-	return nil
-}
-
-// @todo Check adding and removing team labels (make swimlane updates on it)
-// @todo Add media team if need
-func getDashboardID(newLabels []string) int {
-	for _, label := range newLabels {
-		if label == recyclingTeamLabel {
-			return recyclingTeamDashboardID
-		}
+	staleOps, err := reconciler.CleanupStaleSprintSwimlanes(ctx, route)
+	if err != nil {
+		recordSwimlaneOperationError("fetch_swimlanes")
+		return err
 	}
 
-	return 0
+	return reconciler.Apply(ctx, route.DashboardID, append(ops, staleOps...))
 }
 
-func removeSwimlane(dashboardID int, swimlaneID int) error {
-	uri := fmt.Sprintf("%s/rest/greenhopper/1.0/swimlanes/%d/%d", apiBaseURI, dashboardID, swimlaneID)
+func removeSwimlane(ctx context.Context, client *Client, dashboardID int, swimlaneID int) error {
+	uri := fmt.Sprintf("%s/rest/greenhopper/1.0/swimlanes/%d/%d", client.config.BaseURI, dashboardID, swimlaneID)
 
-	return deleteFromJiraAPI(uri)
+	return client.Delete(ctx, uri)
 }
 
-func createSwimlane(dashboardID int, updates swimlaneUpdates) error {
-	uri := fmt.Sprintf("%s/rest/greenhopper/1.0/swimlanes/%d/", apiBaseURI, dashboardID)
+func createSwimlane(ctx context.Context, client *Client, dashboardID int, name string, query string) error {
+	uri := fmt.Sprintf("%s/rest/greenhopper/1.0/swimlanes/%d/", client.config.BaseURI, dashboardID)
 
-	data := map[string]string{"name": updates.Name, "query": updates.Query}
+	data := map[string]string{"name": name, "query": query}
 
 	body, err := json.Marshal(data)
 	if err != nil {
 		return errors.Wrap(err, "Can not create swimlane")
 	}
 
-	_, err = postToJiraAPI(uri, []byte(body))
+	_, err = client.Post(ctx, uri, body)
 
 	return err
 }
 
-func getSwimlaneUpdates(dashboardID int, newIssue issue, oldLabels []string, newLabels []string) (swimlaneUpdates, error) {
-	currentSwimlanes, err := getCurrentSwimlanes(dashboardID)
-	if err != nil {
-		return swimlaneUpdates{}, err
-	}
-
-	swimlaneName := getSwimlaneName(newIssue)
-	needToCreateSwimlanes := isNeedToCreateSwimlane(newLabels, oldLabels)
-	if needToCreateSwimlanes && dashboardSwimlaneAlreadyExists(currentSwimlanes, swimlaneName) {
-		return swimlaneUpdates{}, nil
-	}
-
-	result := swimlaneUpdates{}
-
-	if needToCreateSwimlanes {
-		result = swimlaneUpdates{
-			Name:   swimlaneName,
-			Action: createAction,
-			Query:  getSwimlaneQuery(newIssue.Key),
-		}
+// labelsForReconciliation picks newIssue's labels as of this webhook
+// delivery: the changelog's new label set when one is present, or
+// newIssue's current labels when it isn't (e.g. a jira:issue_created
+// event, which never carries a changelog).
+func labelsForReconciliation(newIssue issue, changelog changelog) []string {
+	if len(changelog.Dataset.Items) == 0 {
+		return getLabelsField(newIssue.Fields)
 	}
 
-	if isNeedToRemoveSwimlane(newLabels, oldLabels) {
-		result = swimlaneUpdates{
-			ID:     getSwimlaneID(swimlaneName, currentSwimlanes),
-			Name:   swimlaneName,
-			Action: removeAction,
-		}
-	}
+	_, newLabels := getLabelsFromChangelog(changelog.Dataset.Items)
 
-	return result, nil
+	return newLabels
 }
 
 func getLabelsFromChangelog(items []changelogItem) ([]string, []string) {
@@ -178,63 +168,66 @@ func getLabelsFromChangelog(items []changelogItem) ([]string, []string) {
 	return nil, nil
 }
 
-func getSwimlaneID(name string, swimlanes []swimlane) int {
-	for _, swimlane := range swimlanes {
-		if swimlane.Name == name {
-			return swimlane.ID
-		}
-	}
+var sprintSwimlaneFilter = regexp.MustCompile(`labels([[:space:]])*=([[:space:]])*"?(.+-sprint-[0-9]+)"?`)
 
-	return 0
+type sprintInfo struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
 }
 
-func getSprintLabel(swimlanes []swimlane) string {
-	sprintSwimlaneFilter := "labels([[:space:]])*=([[:space:]])*(.+-sprint-[0-9]+)"
+type sprintQueryResponse struct {
+	Sprints []sprintInfo `json:"sprints"`
+}
 
-	for _, swimlane := range swimlanes {
-		isSprintSwimlane, _ := regexp.MatchString(sprintSwimlaneFilter, swimlane.Query)
-		if !isSprintSwimlane {
-			continue
-		}
-		r, _ := regexp.Compile("labels([[:space:]])*=([[:space:]])*(.+-sprint-[0-9]+)")
-		sprintSwimlaneText := r.FindString(swimlane.Query)
-		sprintSwimlaneParts := strings.Split(sprintSwimlaneText, "= ")
-		return sprintSwimlaneParts[1]
+// getActiveSprintLabel asks Jira which sprint is currently active on
+// dashboardID, rather than guessing from the swimlanes already on the
+// board (swimlane list order isn't a recency signal, and right after a
+// sprint rolls over the board legitimately has both the old and the new
+// sprint's swimlanes on it at once).
+func getActiveSprintLabel(ctx context.Context, client *Client, dashboardID int) (string, error) {
+	uri := fmt.Sprintf("%s/rest/greenhopper/1.0/sprintquery/%d", client.config.BaseURI, dashboardID)
+
+	body, err := client.Get(ctx, uri)
+	if err != nil {
+		return "", errors.Wrapf(err, "Can not get active sprint for dashboard %d", dashboardID)
 	}
 
-	return ""
-}
+	response := sprintQueryResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", errors.Wrapf(err, "Can not decode active sprint for dashboard %d", dashboardID)
+	}
 
-func isNeedToCreateSwimlane(newLabels []string, oldLabels []string) bool {
-	return has(newLabels, swimlaneStoryLabel) && !has(oldLabels, swimlaneStoryLabel)
-}
+	for _, sprint := range response.Sprints {
+		if sprint.State == "ACTIVE" {
+			return sprint.Name, nil
+		}
+	}
 
-func isNeedToRemoveSwimlane(newLabels []string, oldLabels []string) bool {
-	return !has(newLabels, swimlaneStoryLabel) && has(oldLabels, swimlaneStoryLabel)
+	return "", nil
 }
 
-func dashboardSwimlaneAlreadyExists(currentSwimlanes []swimlane, newSwimlane string) bool {
-	for _, swimlane := range currentSwimlanes {
-		if swimlane.Name == newSwimlane {
-			return true
-		}
+// swimlaneSprintLabel extracts the sprint label from a swimlane's JQL
+// query, or "" if the query isn't sprint-scoped. The label capture group
+// strips the surrounding quotes a JQL string literal (e.g.
+// `labels = "team-sprint-6"`) would otherwise leave in place, so the
+// result compares equal to getActiveSprintLabel's bare sprint name.
+func swimlaneSprintLabel(swimlane swimlane) string {
+	match := sprintSwimlaneFilter.FindStringSubmatch(swimlane.Query)
+	if match == nil {
+		return ""
 	}
 
-	return false
+	return strings.Trim(match[3], `" `)
 }
 
-func getSwimlaneName(issue issue) string {
+func getSwimlaneSummary(issue issue) string {
 	for _, field := range issue.Fields {
 		if field.ID == summaryFieldID {
-			return fmt.Sprintf("<%s> %s", issue.Key, field.Text)
+			return field.Text
 		}
 	}
 
-	return fmt.Sprintf("<%s> No summary", issue.Key)
-}
-
-func getSwimlaneQuery(issueKey string) string {
-	return fmt.Sprintf("issue in linkedIssues(%s)", issueKey)
+	return "No summary"
 }
 
 func getLabelsField(fields []field) []string {
@@ -261,14 +254,14 @@ func has(array []string, value string) bool {
 }
 
 // GET current issue state
-func getCurrentIssue(key string) (issue, error) {
+func getCurrentIssue(ctx context.Context, client *Client, key string) (issue, error) {
 	uri := fmt.Sprintf(
 		"%s/rest/greenhopper/1.0/xboard/issue/details.json?rapidViewId=368&issueIdOrKey=%s",
-		apiBaseURI,
+		client.config.BaseURI,
 		key,
 	)
 
-	body, err := getFromJiraAPI(uri)
+	body, err := client.Get(ctx, uri)
 	if err != nil {
 		return issue{}, errors.Wrapf(err, "Can not get current issue %s", key)
 	}
@@ -280,8 +273,8 @@ func getCurrentIssue(key string) (issue, error) {
 }
 
 // GET dashboard current swimlanes
-func getCurrentSwimlanes(dashboardID int) ([]swimlane, error) {
-	dashboard, err := getCurrentDashboard(dashboardID)
+func getCurrentSwimlanes(ctx context.Context, client *Client, dashboardID int) ([]swimlane, error) {
+	dashboard, err := getCurrentDashboard(ctx, client, dashboardID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "Can not get current swimlanes")
 	}
@@ -290,14 +283,14 @@ func getCurrentSwimlanes(dashboardID int) ([]swimlane, error) {
 }
 
 // GET dashboard current settings
-func getCurrentDashboard(ID int) (dashboard, error) {
+func getCurrentDashboard(ctx context.Context, client *Client, ID int) (dashboard, error) {
 	uri := fmt.Sprintf(
 		"%s/rest/greenhopper/1.0/xboard/config.json?returnDefaultBoard=false&rapidViewId=%d",
-		apiBaseURI,
+		client.config.BaseURI,
 		ID,
 	)
 
-	body, err := getFromJiraAPI(uri)
+	body, err := client.Get(ctx, uri)
 	if err != nil {
 		return dashboard{}, errors.Wrapf(err, "Can not get current dashboard %d", ID)
 	}
@@ -307,135 +300,3 @@ func getCurrentDashboard(ID int) (dashboard, error) {
 
 	return dashboard, nil
 }
-
-// GET request to JIRA API
-func getFromJiraAPI(uri string) ([]byte, error) {
-	authData, err := login()
-	if err != nil {
-		return nil, err
-	}
-
-	request, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, errors.Wrapf(err, "Jira api request building failed %s", uri)
-	}
-
-	request.Header.Add(
-		"Cookie",
-		fmt.Sprintf("%s=%s", authData.Session.Name, authData.Session.Value),
-	)
-	request.Header.Add("X-Atlassian-Token", "no-check")
-
-	client := &http.Client{}
-	response, err := client.Do(request)
-
-	if err != nil {
-		return nil, errors.Wrapf(err, "Jira api request failed %s", uri)
-	}
-
-	defer response.Body.Close()
-	body, err := ioutil.ReadAll(response.Body)
-
-	if err != nil {
-		return nil, errors.Wrapf(err, "Jira api request failed %s", uri)
-	}
-
-	return body, nil
-}
-
-func postToJiraAPI(uri string, data []byte) ([]byte, error) {
-	authData, err := login()
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := json.Marshal(data)
-	if err != nil {
-		return nil, errors.Wrap(err, "Post request failed")
-	}
-
-	request, err := http.NewRequest("POST", uri, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, errors.Wrapf(err, "Jira api request building failed %s", uri)
-	}
-
-	request.Header.Add(
-		"Cookie",
-		fmt.Sprintf("%s=%s", authData.Session.Name, authData.Session.Value),
-	)
-	request.Header.Add("X-Atlassian-Token", "no-check")
-
-	client := &http.Client{}
-	response, err := client.Do(request)
-
-	if err != nil {
-		return nil, errors.Wrapf(err, "Jira api request failed %s", uri)
-	}
-
-	defer response.Body.Close()
-	result, err := ioutil.ReadAll(response.Body)
-
-	if err != nil {
-		return nil, errors.Wrapf(err, "Jira api request failed %s", uri)
-	}
-
-	return result, nil
-}
-
-func deleteFromJiraAPI(uri string) error {
-	authData, err := login()
-	if err != nil {
-		return err
-	}
-
-	request, err := http.NewRequest("DELETE", uri, nil)
-	if err != nil {
-		return errors.Wrapf(err, "Jira api request building failed %s", uri)
-	}
-
-	request.Header.Add(
-		"Cookie",
-		fmt.Sprintf("%s=%s", authData.Session.Name, authData.Session.Value),
-	)
-	request.Header.Add("X-Atlassian-Token", "no-check")
-
-	client := &http.Client{}
-	response, err := client.Do(request)
-
-	if err != nil {
-		return errors.Wrapf(err, "Jira api request failed %s", uri)
-	}
-
-	defer response.Body.Close()
-
-	return nil
-}
-
-// POST request to login to JIRA API
-func login() (authData, error) {
-	loginData := map[string]string{"username": username, "password": password}
-
-	request, err := json.Marshal(loginData)
-	if err != nil {
-		return authData{}, errors.Wrap(err, "Auth failed")
-	}
-
-	response, err := http.Post(loginURI, "application/json", bytes.NewBuffer(request))
-	if err != nil {
-		return authData{}, errors.Wrap(err, "Auth failed")
-	}
-
-	defer response.Body.Close()
-	body, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return authData{}, errors.Wrap(err, "Auth failed")
-	}
-
-	result := authData{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		return authData{}, errors.Wrap(err, "Auth failed")
-	}
-
-	return result, nil
-}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+)
+
+// logger is the process-wide structured logger. Every log site attaches
+// issue key, dashboard ID, labels and correlation ID where available so
+// label-routing regressions can be traced back to the webhook delivery
+// that caused them.
+var logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	configPath := flag.String("config", "config.yaml", "path to the server config file")
+	insecure := flag.Bool("insecure", false, "allow running with webhook signature verification disabled (shared_secret empty)")
+	flag.Parse()
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("swimlane-auto-create: %v", err)
+	}
+
+	client := NewClient(ClientConfig{
+		BaseURI:  cfg.JiraBaseURI,
+		LoginURI: cfg.JiraLoginURI,
+		AuthMode: cfg.authMode(),
+		Username: cfg.JiraUsername,
+		Password: cfg.JiraPassword,
+		Token:    cfg.JiraToken,
+	})
+
+	router, err := NewRouter(cfg.Routes)
+	if err != nil {
+		log.Fatalf("swimlane-auto-create: %v", err)
+	}
+
+	server, err := NewWebhookServer(WebhookConfig{
+		ListenAddr:    cfg.ListenAddr,
+		Path:          cfg.WebhookPath,
+		SharedSecret:  cfg.SharedSecret,
+		Client:        client,
+		Router:        router,
+		AllowUnsigned: *insecure || cfg.AllowUnsignedWebhooks,
+	})
+	if err != nil {
+		log.Fatalf("swimlane-auto-create: %v", err)
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("swimlane-auto-create: webhook server failed: %v", err)
+		}
+	}()
+
+	go watchForReload(server, *configPath)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("swimlane-auto-create: graceful shutdown failed: %v", err)
+	}
+}
+
+// watchForReload rebuilds the router from configPath on every SIGHUP,
+// swapping it into server so routing rule changes take effect without a
+// restart.
+func watchForReload(server *WebhookServer, configPath string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		cfg, err := loadConfig(configPath)
+		if err != nil {
+			log.Printf("swimlane-auto-create: config reload failed: %v", err)
+			continue
+		}
+
+		router, err := NewRouter(cfg.Routes)
+		if err != nil {
+			log.Printf("swimlane-auto-create: config reload failed: %v", err)
+			continue
+		}
+
+		server.SetRouter(router)
+		log.Printf("swimlane-auto-create: routing config reloaded from %s", configPath)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	jiraAPIRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jira_api_requests_total",
+		Help: "Total Jira API requests, by HTTP method and response status.",
+	}, []string{"method", "status"})
+
+	jiraAPIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "jira_api_request_duration_seconds",
+		Help:    "Jira API request latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "status"})
+
+	swimlaneOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "swimlane_operations_total",
+		Help: "Total swimlane operations, by action and result.",
+	}, []string{"action", "result"})
+
+	swimlaneOperationErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "swimlane_operation_errors_total",
+		Help: "Total swimlane operation errors, by reason.",
+	}, []string{"reason"})
+)
+
+// metricsHandler exposes operational metrics in Prometheus text format.
+func metricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+func recordSwimlaneOperation(action string, result string) {
+	swimlaneOperationsTotal.WithLabelValues(action, result).Inc()
+}
+
+func recordSwimlaneOperationError(reason string) {
+	swimlaneOperationErrorsTotal.WithLabelValues(reason).Inc()
+}
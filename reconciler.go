@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// reconcileOp is one create/remove operation needed to bring a
+// dashboard's swimlanes in line with the desired state for an issue.
+type reconcileOp struct {
+	Action string
+	ID     int
+	Name   string
+	Query  string
+}
+
+// BackoffConfig bounds the retry/backoff applied when a swimlane
+// create/remove call hits a transient Jira error.
+type BackoffConfig struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func defaultBackoffConfig() BackoffConfig {
+	return BackoffConfig{MaxRetries: 5, BaseDelay: 200 * time.Millisecond, MaxDelay: 10 * time.Second}
+}
+
+func (b BackoffConfig) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	delay := b.BaseDelay * time.Duration(int64(1)<<uint(attempt))
+	if delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// Reconciler computes the diff between a route's desired and actual
+// swimlane state for an issue and applies it idempotently: no-ops are
+// skipped, transient Jira errors are retried with backoff, and DryRun
+// mode logs the plan instead of calling the Jira API.
+type Reconciler struct {
+	client  *Client
+	dryRun  bool
+	backoff BackoffConfig
+}
+
+// NewReconciler builds a Reconciler with the repo's default retry
+// backoff.
+func NewReconciler(client *Client, dryRun bool) *Reconciler {
+	return &Reconciler{client: client, dryRun: dryRun, backoff: defaultBackoffConfig()}
+}
+
+// Plan computes the create/remove operations, if any, needed to bring
+// route's dashboard in line with newIssue's desired swimlane state. A
+// nil, nil result means the dashboard already matches the desired
+// state — callers should treat this as a no-op, not an error.
+func (r *Reconciler) Plan(ctx context.Context, route Route, newIssue issue, newLabels []string) ([]reconcileOp, error) {
+	currentSwimlanes, err := getCurrentSwimlanes(ctx, r.client, route.DashboardID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := route.Name(newIssue, newLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	wantsSwimlane := has(newLabels, swimlaneStoryLabel)
+	existing := findSwimlane(currentSwimlanes, name)
+
+	if wantsSwimlane && existing == nil {
+		sprintLabel := ""
+		if route.SprintAware {
+			sprintLabel, err = getActiveSprintLabel(ctx, r.client, route.DashboardID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		query, err := route.Query(newIssue, newLabels, sprintLabel)
+		if err != nil {
+			return nil, err
+		}
+
+		return []reconcileOp{{Action: createAction, Name: name, Query: query}}, nil
+	}
+
+	if !wantsSwimlane && existing != nil {
+		return []reconcileOp{{Action: removeAction, ID: existing.ID, Name: name}}, nil
+	}
+
+	return nil, nil
+}
+
+// CleanupStaleSprintSwimlanes finds swimlanes on route's dashboard whose
+// embedded sprint label no longer matches the dashboard's current
+// sprint, and returns the remove operations needed to drop them. It's a
+// no-op for routes that aren't SprintAware.
+func (r *Reconciler) CleanupStaleSprintSwimlanes(ctx context.Context, route Route) ([]reconcileOp, error) {
+	if !route.SprintAware {
+		return nil, nil
+	}
+
+	currentSprint, err := getActiveSprintLabel(ctx, r.client, route.DashboardID)
+	if err != nil {
+		return nil, err
+	}
+	if currentSprint == "" {
+		return nil, nil
+	}
+
+	currentSwimlanes, err := getCurrentSwimlanes(ctx, r.client, route.DashboardID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []reconcileOp
+	for _, sw := range currentSwimlanes {
+		label := swimlaneSprintLabel(sw)
+		if label == "" || label == currentSprint {
+			continue
+		}
+
+		ops = append(ops, reconcileOp{Action: removeAction, ID: sw.ID, Name: sw.Name})
+	}
+
+	return ops, nil
+}
+
+func findSwimlane(swimlanes []swimlane, name string) *swimlane {
+	for i := range swimlanes {
+		if swimlanes[i].Name == name {
+			return &swimlanes[i]
+		}
+	}
+
+	return nil
+}
+
+// Apply runs ops against dashboardID, retrying 5xx/429 responses with
+// backoff (honoring Retry-After) and recording swimlane operation
+// metrics for each attempt. In DryRun mode it only logs the planned
+// change.
+func (r *Reconciler) Apply(ctx context.Context, dashboardID int, ops []reconcileOp) error {
+	var firstErr error
+
+	for _, op := range ops {
+		if r.dryRun {
+			logger.Info().
+				Str("action", op.Action).
+				Int("dashboard_id", dashboardID).
+				Str("name", op.Name).
+				Msg("dry-run: skipping swimlane operation")
+			recordSwimlaneOperation(op.Action, "dry_run")
+
+			continue
+		}
+
+		err := r.applyWithRetry(ctx, dashboardID, op)
+		recordSwimlaneOperation(op.Action, swimlaneOperationResult(err))
+		if err != nil {
+			recordSwimlaneOperationError(swimlaneOperationFailureReason(op.Action, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func (r *Reconciler) applyWithRetry(ctx context.Context, dashboardID int, op reconcileOp) error {
+	var lastErr error
+
+	for attempt := 0; attempt <= r.backoff.MaxRetries; attempt++ {
+		var err error
+		switch op.Action {
+		case createAction:
+			err = createSwimlane(ctx, r.client, dashboardID, op.Name, op.Query)
+		case removeAction:
+			err = removeSwimlane(ctx, r.client, dashboardID, op.ID)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+
+		apiErr, retryable := err.(*apiError)
+		if !retryable || !apiErr.Retryable() || attempt == r.backoff.MaxRetries {
+			return lastErr
+		}
+
+		delay := r.backoff.delay(attempt, apiErr.RetryAfter)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	return lastErr
+}
+
+func swimlaneOperationResult(err error) string {
+	if err != nil {
+		return "error"
+	}
+
+	return "success"
+}
+
+// swimlaneOperationFailureReason turns a failed create/remove's error into
+// a reason label distinct from the action itself, so
+// swimlane_operation_errors_total{reason} can still tell an operator why an
+// operation failed (e.g. retries exhausted vs. a non-retryable Jira
+// response) rather than just repeating "create"/"remove".
+func swimlaneOperationFailureReason(action string, err error) string {
+	if stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return fmt.Sprintf("%s_context_done", action)
+	}
+
+	if apiErr, ok := err.(*apiError); ok {
+		if apiErr.Retryable() {
+			return fmt.Sprintf("%s_max_retries_exceeded", action)
+		}
+
+		return fmt.Sprintf("%s_status_%d", action, apiErr.StatusCode)
+	}
+
+	return fmt.Sprintf("%s_request_error", action)
+}
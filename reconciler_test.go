@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// routedTransport dispatches each request to a handler keyed by a
+// substring of the request URL, so a single fake Client can stand in for
+// the several distinct Jira endpoints a Reconciler calls.
+type routedTransport struct {
+	routes []struct {
+		contains string
+		handler  func(*http.Request) (*http.Response, error)
+	}
+}
+
+func (t *routedTransport) on(contains string, handler func(*http.Request) (*http.Response, error)) {
+	t.routes = append(t.routes, struct {
+		contains string
+		handler  func(*http.Request) (*http.Response, error)
+	}{contains, handler})
+}
+
+func (t *routedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	for _, route := range t.routes {
+		if strings.Contains(request.URL.String(), route.contains) {
+			return route.handler(request)
+		}
+	}
+
+	return jsonResponse(http.StatusNotFound, `{}`)(request)
+}
+
+func newTestClient(transport http.RoundTripper) *Client {
+	return NewClient(ClientConfig{
+		BaseURI:   "http://jira.example",
+		AuthMode:  AuthModeBearer,
+		Token:     "tok-123",
+		Transport: transport,
+	})
+}
+
+func TestCleanupStaleSprintSwimlanesKeepsTheActiveSprintAndRemovesOthers(t *testing.T) {
+	// Regression test for the bug where the "current sprint" was
+	// guessed from swimlane list order: sprint-5 (stale) is listed
+	// before sprint-6 (the dashboard's actual active sprint).
+	transport := &routedTransport{}
+	transport.on("sprintquery", jsonResponse(http.StatusOK, `{
+		"sprints": [
+			{"name": "team-sprint-5", "state": "CLOSED"},
+			{"name": "team-sprint-6", "state": "ACTIVE"}
+		]
+	}`))
+	transport.on("xboard/config.json", jsonResponse(http.StatusOK, `{
+		"currentViewConfig": {
+			"swimlanes": [
+				{"id": 1, "name": "Team A", "query": "labels = team-sprint-5"},
+				{"id": 2, "name": "Team B", "query": "labels = team-sprint-6"}
+			]
+		}
+	}`))
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	route := Route{DashboardID: 42, SprintAware: true}
+
+	ops, err := reconciler.CleanupStaleSprintSwimlanes(context.Background(), route)
+	if err != nil {
+		t.Fatalf("CleanupStaleSprintSwimlanes returned error: %v", err)
+	}
+
+	if len(ops) != 1 || ops[0].ID != 1 || ops[0].Action != removeAction {
+		t.Fatalf("expected exactly the stale sprint-5 swimlane (id 1) to be removed, got %+v", ops)
+	}
+}
+
+func TestCleanupStaleSprintSwimlanesHandlesQuotedJQLStringLiterals(t *testing.T) {
+	// Regression test: query_template docs recommend quoting the sprint
+	// label (`labels = "team-sprint-6"`), since it's a JQL string
+	// literal. swimlaneSprintLabel must strip that quoting so it
+	// compares equal to getActiveSprintLabel's bare sprint name -
+	// otherwise the swimlane this route just created for the active
+	// sprint looks stale and gets deleted on the very next event.
+	transport := &routedTransport{}
+	transport.on("sprintquery", jsonResponse(http.StatusOK, `{
+		"sprints": [
+			{"name": "team-sprint-6", "state": "ACTIVE"}
+		]
+	}`))
+	transport.on("xboard/config.json", jsonResponse(http.StatusOK, `{
+		"currentViewConfig": {
+			"swimlanes": [
+				{"id": 1, "name": "Team A", "query": "labels = \"team-sprint-6\""}
+			]
+		}
+	}`))
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	route := Route{DashboardID: 42, SprintAware: true}
+
+	ops, err := reconciler.CleanupStaleSprintSwimlanes(context.Background(), route)
+	if err != nil {
+		t.Fatalf("CleanupStaleSprintSwimlanes returned error: %v", err)
+	}
+
+	if len(ops) != 0 {
+		t.Fatalf("expected the active sprint's quoted swimlane to survive, got %+v", ops)
+	}
+}
+
+func TestCleanupStaleSprintSwimlanesNoOpsWhenNoSprintIsActive(t *testing.T) {
+	transport := &routedTransport{}
+	transport.on("sprintquery", jsonResponse(http.StatusOK, `{"sprints": []}`))
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	route := Route{DashboardID: 42, SprintAware: true}
+
+	ops, err := reconciler.CleanupStaleSprintSwimlanes(context.Background(), route)
+	if err != nil {
+		t.Fatalf("CleanupStaleSprintSwimlanes returned error: %v", err)
+	}
+	if ops != nil {
+		t.Fatalf("expected no ops when no sprint is active, got %+v", ops)
+	}
+}
+
+func TestCleanupStaleSprintSwimlanesIsANoOpForNonSprintAwareRoutes(t *testing.T) {
+	reconciler := NewReconciler(newTestClient(&routedTransport{}), false)
+	route := Route{DashboardID: 42}
+
+	ops, err := reconciler.CleanupStaleSprintSwimlanes(context.Background(), route)
+	if err != nil {
+		t.Fatalf("CleanupStaleSprintSwimlanes returned error: %v", err)
+	}
+	if ops != nil {
+		t.Fatalf("expected nil ops for a non-sprint-aware route, got %+v", ops)
+	}
+}
+
+func testRoute(t *testing.T, dashboardID int, sprintAware bool) Route {
+	t.Helper()
+
+	router, err := NewRouter([]RouteConfig{
+		{
+			Labels:        []string{"team-a"},
+			DashboardID:   dashboardID,
+			NameTemplate:  "{{.Key}} Swimlane",
+			QueryTemplate: "labels = {{.Key}}",
+			SprintAware:   sprintAware,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+
+	routes := router.Match([]string{"team-a"})
+	if len(routes) != 1 {
+		t.Fatalf("expected exactly one route to match, got %d", len(routes))
+	}
+
+	return routes[0]
+}
+
+func TestPlanCreatesSwimlaneWhenWantedAndMissing(t *testing.T) {
+	transport := &routedTransport{}
+	transport.on("xboard/config.json", jsonResponse(http.StatusOK, `{
+		"currentViewConfig": {"swimlanes": []}
+	}`))
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	route := testRoute(t, 7, false)
+	newIssue := issue{Key: "ABC-1"}
+
+	ops, err := reconciler.Plan(context.Background(), route, newIssue, []string{"swimline-story"})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	if len(ops) != 1 || ops[0].Action != createAction || ops[0].Name != "ABC-1 Swimlane" || ops[0].Query != "labels = ABC-1" {
+		t.Fatalf("expected a single create op for the missing swimlane, got %+v", ops)
+	}
+}
+
+func TestPlanRemovesSwimlaneWhenUnwantedAndPresent(t *testing.T) {
+	transport := &routedTransport{}
+	transport.on("xboard/config.json", jsonResponse(http.StatusOK, `{
+		"currentViewConfig": {
+			"swimlanes": [
+				{"id": 9, "name": "ABC-1 Swimlane", "query": "labels = ABC-1"}
+			]
+		}
+	}`))
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	route := testRoute(t, 7, false)
+	newIssue := issue{Key: "ABC-1"}
+
+	ops, err := reconciler.Plan(context.Background(), route, newIssue, []string{})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	if len(ops) != 1 || ops[0].Action != removeAction || ops[0].ID != 9 {
+		t.Fatalf("expected a single remove op for the now-unwanted swimlane, got %+v", ops)
+	}
+}
+
+func TestPlanIsANoOpWhenAlreadyCorrect(t *testing.T) {
+	transport := &routedTransport{}
+	transport.on("xboard/config.json", jsonResponse(http.StatusOK, `{
+		"currentViewConfig": {
+			"swimlanes": [
+				{"id": 9, "name": "ABC-1 Swimlane", "query": "labels = ABC-1"}
+			]
+		}
+	}`))
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	route := testRoute(t, 7, false)
+	newIssue := issue{Key: "ABC-1"}
+
+	ops, err := reconciler.Plan(context.Background(), route, newIssue, []string{"swimline-story"})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if ops != nil {
+		t.Fatalf("expected no ops when the wanted swimlane already exists, got %+v", ops)
+	}
+}
+
+func TestApplyDryRunSkipsTheJiraAPIAndRecordsADryRunMetric(t *testing.T) {
+	transport := &routedTransport{}
+	transport.on("swimlanes", func(request *http.Request) (*http.Response, error) {
+		t.Fatal("dry-run Apply must not call the Jira API")
+		return nil, nil
+	})
+
+	reconciler := NewReconciler(newTestClient(transport), true)
+	before := testutil.ToFloat64(swimlaneOperationsTotal.WithLabelValues(createAction, "dry_run"))
+
+	err := reconciler.Apply(context.Background(), 42, []reconcileOp{{Action: createAction, Name: "ABC-1 Swimlane", Query: "labels = ABC-1"}})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	after := testutil.ToFloat64(swimlaneOperationsTotal.WithLabelValues(createAction, "dry_run"))
+	if after != before+1 {
+		t.Fatalf("expected the dry_run metric to increment by 1, got %v -> %v", before, after)
+	}
+}
+
+func TestApplyWithRetryRetriesRetryableErrorsThenSucceeds(t *testing.T) {
+	attempts := 0
+	transport := &routedTransport{}
+	transport.on("swimlanes", func(request *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts < 3 {
+			return jsonResponse(http.StatusServiceUnavailable, `{}`)(request)
+		}
+		return jsonResponse(http.StatusOK, `{}`)(request)
+	})
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	reconciler.backoff = BackoffConfig{MaxRetries: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	err := reconciler.Apply(context.Background(), 42, []reconcileOp{{Action: createAction, Name: "Team A", Query: "labels = x"}})
+	if err != nil {
+		t.Fatalf("Apply returned error after retries should have succeeded: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts (2 failures + 1 success), got %d", attempts)
+	}
+}
+
+func TestApplyDoesNotRetryNonRetryableErrors(t *testing.T) {
+	attempts := 0
+	transport := &routedTransport{}
+	transport.on("swimlanes", func(request *http.Request) (*http.Response, error) {
+		attempts++
+		return jsonResponse(http.StatusBadRequest, `{}`)(request)
+	})
+
+	reconciler := NewReconciler(newTestClient(transport), false)
+	reconciler.backoff = BackoffConfig{MaxRetries: 5, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	err := reconciler.Apply(context.Background(), 42, []reconcileOp{{Action: createAction, Name: "Team A", Query: "labels = x"}})
+	if err == nil {
+		t.Fatal("expected Apply to surface the non-retryable error")
+	}
+	if attempts != 1 {
+		t.Fatalf("expected exactly 1 attempt for a non-retryable error, got %d", attempts)
+	}
+}
+
+func TestSwimlaneOperationFailureReasonDistinguishesFailureModes(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"context canceled", context.Canceled, "create_context_done"},
+		{"retryable api error", &apiError{StatusCode: http.StatusServiceUnavailable}, "create_max_retries_exceeded"},
+		{"non-retryable api error", &apiError{StatusCode: http.StatusBadRequest}, "create_status_400"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := swimlaneOperationFailureReason(createAction, tc.err)
+			if got != tc.want {
+				t.Errorf("got reason %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// RouteConfig describes one team's label-to-dashboard routing rule as
+// loaded from config.
+type RouteConfig struct {
+	Labels        []string `yaml:"labels"`
+	MatchAll      bool     `yaml:"match_all"`
+	DashboardID   int      `yaml:"dashboard_id"`
+	NameTemplate  string   `yaml:"name_template"`
+	QueryTemplate string   `yaml:"query_template"`
+	// DryRun, when set, makes the reconciler log planned swimlane
+	// changes for this route instead of applying them.
+	DryRun bool `yaml:"dry_run"`
+	// SprintAware scopes created swimlanes to the dashboard's current
+	// sprint label (via {{.SprintLabel}} in QueryTemplate) and enables
+	// the stale-sprint-swimlane cleanup pass. Teams that don't use
+	// sprint labels should leave this unset.
+	SprintAware bool `yaml:"sprint_aware"`
+}
+
+// routeContext supplies the fields available to a Route's name and
+// query templates.
+type routeContext struct {
+	Key         string
+	Summary     string
+	Labels      []string
+	SprintLabel string
+}
+
+// Route is a resolved routing target for an issue: which dashboard to
+// create or remove a swimlane on, and how to render its name and JQL
+// query.
+type Route struct {
+	DashboardID   int
+	DryRun        bool
+	SprintAware   bool
+	nameTemplate  *template.Template
+	queryTemplate *template.Template
+}
+
+// Name renders the route's swimlane name template for newIssue.
+func (route Route) Name(newIssue issue, labels []string) (string, error) {
+	return route.render(route.nameTemplate, newIssue, labels, "")
+}
+
+// Query renders the route's JQL query template for newIssue. sprintLabel
+// is only meaningful (and only non-empty) for SprintAware routes.
+func (route Route) Query(newIssue issue, labels []string, sprintLabel string) (string, error) {
+	return route.render(route.queryTemplate, newIssue, labels, sprintLabel)
+}
+
+func (route Route) render(tmpl *template.Template, newIssue issue, labels []string, sprintLabel string) (string, error) {
+	ctx := routeContext{
+		Key:         newIssue.Key,
+		Summary:     getSwimlaneSummary(newIssue),
+		Labels:      labels,
+		SprintLabel: sprintLabel,
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, ctx); err != nil {
+		return "", errors.Wrap(err, "Can not render route template")
+	}
+
+	return buf.String(), nil
+}
+
+type compiledRoute struct {
+	labels        []string
+	matchAll      bool
+	dashboardID   int
+	dryRun        bool
+	sprintAware   bool
+	nameTemplate  *template.Template
+	queryTemplate *template.Template
+}
+
+// Router resolves an issue's labels to zero or more Routes, replacing
+// the old hardcoded recyclingTeamLabel/recyclingTeamDashboardID pair so
+// a single issue can create swimlanes on multiple team boards (e.g. the
+// media team alongside recycling).
+type Router struct {
+	rules []compiledRoute
+}
+
+// NewRouter compiles routeConfigs into a Router.
+func NewRouter(routeConfigs []RouteConfig) (*Router, error) {
+	rules := make([]compiledRoute, 0, len(routeConfigs))
+
+	for _, rc := range routeConfigs {
+		nameTmpl, err := template.New("name").Parse(rc.NameTemplate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid name_template for dashboard %d", rc.DashboardID)
+		}
+
+		queryTmpl, err := template.New("query").Parse(rc.QueryTemplate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid query_template for dashboard %d", rc.DashboardID)
+		}
+
+		rules = append(rules, compiledRoute{
+			labels:        rc.Labels,
+			matchAll:      rc.MatchAll,
+			dashboardID:   rc.DashboardID,
+			dryRun:        rc.DryRun,
+			sprintAware:   rc.SprintAware,
+			nameTemplate:  nameTmpl,
+			queryTemplate: queryTmpl,
+		})
+	}
+
+	return &Router{rules: rules}, nil
+}
+
+// Match returns every Route whose label condition is satisfied by
+// labels. A rule with MatchAll requires every configured label to be
+// present; otherwise any one of them is enough.
+func (r *Router) Match(labels []string) []Route {
+	matches := []Route{}
+
+	for _, rule := range r.rules {
+		if !rule.matches(labels) {
+			continue
+		}
+
+		matches = append(matches, Route{
+			DashboardID:   rule.dashboardID,
+			DryRun:        rule.dryRun,
+			SprintAware:   rule.sprintAware,
+			nameTemplate:  rule.nameTemplate,
+			queryTemplate: rule.queryTemplate,
+		})
+	}
+
+	return matches
+}
+
+func (rule compiledRoute) matches(labels []string) bool {
+	if len(rule.labels) == 0 {
+		return false
+	}
+
+	if rule.matchAll {
+		for _, want := range rule.labels {
+			if !has(labels, want) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for _, want := range rule.labels {
+		if has(labels, want) {
+			return true
+		}
+	}
+
+	return false
+}
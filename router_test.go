@@ -0,0 +1,91 @@
+package main
+
+import "testing"
+
+func TestRouterMatch(t *testing.T) {
+	cases := []struct {
+		name          string
+		routeConfigs  []RouteConfig
+		labels        []string
+		wantDashboard []int
+	}{
+		{
+			name: "any-of match fires on a single shared label",
+			routeConfigs: []RouteConfig{
+				{Labels: []string{"team-recycling"}, DashboardID: 1, NameTemplate: "n", QueryTemplate: "q"},
+			},
+			labels:        []string{"team-recycling", "swimline-story"},
+			wantDashboard: []int{1},
+		},
+		{
+			name: "any-of match does not fire without any shared label",
+			routeConfigs: []RouteConfig{
+				{Labels: []string{"team-recycling"}, DashboardID: 1, NameTemplate: "n", QueryTemplate: "q"},
+			},
+			labels:        []string{"team-media"},
+			wantDashboard: nil,
+		},
+		{
+			name: "match_all requires every configured label",
+			routeConfigs: []RouteConfig{
+				{Labels: []string{"team-recycling", "swimline-story"}, MatchAll: true, DashboardID: 1, NameTemplate: "n", QueryTemplate: "q"},
+			},
+			labels:        []string{"team-recycling"},
+			wantDashboard: nil,
+		},
+		{
+			name: "match_all fires once every configured label is present",
+			routeConfigs: []RouteConfig{
+				{Labels: []string{"team-recycling", "swimline-story"}, MatchAll: true, DashboardID: 1, NameTemplate: "n", QueryTemplate: "q"},
+			},
+			labels:        []string{"team-recycling", "swimline-story"},
+			wantDashboard: []int{1},
+		},
+		{
+			name: "a label can resolve to routes on multiple dashboards",
+			routeConfigs: []RouteConfig{
+				{Labels: []string{"swimline-story"}, DashboardID: 1, NameTemplate: "n", QueryTemplate: "q"},
+				{Labels: []string{"swimline-story"}, DashboardID: 2, NameTemplate: "n", QueryTemplate: "q"},
+			},
+			labels:        []string{"swimline-story"},
+			wantDashboard: []int{1, 2},
+		},
+		{
+			name: "a rule with no configured labels never matches",
+			routeConfigs: []RouteConfig{
+				{DashboardID: 1, NameTemplate: "n", QueryTemplate: "q"},
+			},
+			labels:        []string{"swimline-story"},
+			wantDashboard: nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, err := NewRouter(tc.routeConfigs)
+			if err != nil {
+				t.Fatalf("NewRouter returned error: %v", err)
+			}
+
+			routes := router.Match(tc.labels)
+			if len(routes) != len(tc.wantDashboard) {
+				t.Fatalf("got %d routes, want %d", len(routes), len(tc.wantDashboard))
+			}
+
+			for i, route := range routes {
+				if route.DashboardID != tc.wantDashboard[i] {
+					t.Errorf("route %d: got dashboard %d, want %d", i, route.DashboardID, tc.wantDashboard[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewRouterRejectsInvalidTemplate(t *testing.T) {
+	_, err := NewRouter([]RouteConfig{
+		{Labels: []string{"x"}, DashboardID: 1, NameTemplate: "{{.Bogus", QueryTemplate: "q"},
+	})
+	if err == nil {
+		t.Fatal("expected an error for an unparseable name_template")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+const signatureHeader = "X-Webhook-Signature"
+
+// WebhookConfig configures a WebhookServer.
+type WebhookConfig struct {
+	ListenAddr   string
+	Path         string
+	SharedSecret string
+	Client       *Client
+	Router       *Router
+	// AllowUnsigned opts into running with signature verification
+	// disabled when SharedSecret is empty. Without it, NewWebhookServer
+	// refuses to start an unsigned endpoint — a forgotten shared_secret
+	// in config must not silently turn into a public, unauthenticated
+	// webhook.
+	AllowUnsigned bool
+}
+
+// WebhookServer receives Jira issue webhooks and dispatches them to
+// updateDashboardIfNeed. Its Router is held behind an atomic.Value so it
+// can be swapped at runtime (see SetRouter) without locking webhook
+// handling.
+type WebhookServer struct {
+	config WebhookConfig
+	server *http.Server
+	router atomic.Value
+}
+
+// NewWebhookServer builds a WebhookServer from config. It errors instead
+// of starting an unauthenticated endpoint when SharedSecret is empty and
+// AllowUnsigned wasn't explicitly set.
+func NewWebhookServer(config WebhookConfig) (*WebhookServer, error) {
+	if config.SharedSecret == "" && !config.AllowUnsigned {
+		return nil, errors.New("webhook shared_secret is empty; set shared_secret or explicitly set AllowUnsigned/-insecure")
+	}
+
+	s := &WebhookServer{config: config}
+	s.router.Store(config.Router)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(config.Path, s.handleWebhook)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.Handle("/metrics", metricsHandler())
+
+	s.server = &http.Server{Addr: config.ListenAddr, Handler: mux}
+
+	return s, nil
+}
+
+// SetRouter atomically swaps the Router used by subsequent webhook
+// deliveries, letting routing rules be reloaded without downtime.
+func (s *WebhookServer) SetRouter(router *Router) {
+	s.router.Store(router)
+}
+
+func (s *WebhookServer) currentRouter() *Router {
+	return s.router.Load().(*Router)
+}
+
+// ListenAndServe starts the webhook HTTP server. It blocks until the
+// server stops, returning http.ErrServerClosed after a graceful
+// Shutdown.
+func (s *WebhookServer) ListenAndServe() error {
+	return s.server.ListenAndServe()
+}
+
+// Shutdown gracefully stops the webhook HTTP server.
+func (s *WebhookServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// webhookPayload is the subset of a Jira `jira:issue_updated` /
+// `jira:issue_created` webhook body this service cares about.
+type webhookPayload struct {
+	WebhookEvent string          `json:"webhookEvent"`
+	Issue        issue           `json:"issue"`
+	ChangelogRaw json.RawMessage `json:"changelog"`
+}
+
+func (p webhookPayload) changelog() (changelog, error) {
+	items := changelogItems{}
+	if len(p.ChangelogRaw) > 0 {
+		if err := json.Unmarshal(p.ChangelogRaw, &items); err != nil {
+			return changelog{}, errors.Wrap(err, "Can not decode webhook changelog")
+		}
+	}
+
+	return changelog{Dataset: items}, nil
+}
+
+func (s *WebhookServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Can not read request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	if !validSignature(s.config.SharedSecret, body, r.Header.Get(signatureHeader)) {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	payload := webhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Can not decode webhook payload", http.StatusBadRequest)
+		return
+	}
+
+	if payload.WebhookEvent != "jira:issue_updated" && payload.WebhookEvent != "jira:issue_created" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	changelog, err := payload.changelog()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	correlationID := r.Header.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = newCorrelationID()
+	}
+	ctx := withCorrelationID(r.Context(), correlationID)
+
+	if err := updateDashboardIfNeed(ctx, s.config.Client, s.currentRouter(), payload.Issue, changelog); err != nil {
+		logger.Error().
+			Str("correlation_id", correlationID).
+			Str("issue_key", payload.Issue.Key).
+			Err(err).
+			Msg("webhook delivery failed")
+		http.Error(w, "Can not update dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *WebhookServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// validSignature checks the HMAC-SHA256 signature Jira sent in the
+// X-Webhook-Signature header against the raw request body. An empty
+// secret disables verification (useful for local testing).
+func validSignature(secret string, body []byte, signature string) bool {
+	if secret == "" {
+		return true
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewWebhookServerRejectsEmptySharedSecretWithoutAllowUnsigned(t *testing.T) {
+	_, err := NewWebhookServer(WebhookConfig{Path: "/webhook"})
+	if err == nil {
+		t.Fatal("expected NewWebhookServer to reject an empty shared_secret, got nil error")
+	}
+}
+
+func TestNewWebhookServerAllowsEmptySharedSecretWithAllowUnsigned(t *testing.T) {
+	_, err := NewWebhookServer(WebhookConfig{Path: "/webhook", AllowUnsigned: true, Router: &Router{}})
+	if err != nil {
+		t.Fatalf("expected AllowUnsigned to permit an empty shared_secret, got error: %v", err)
+	}
+}
+
+func TestHandleWebhookRejectsBadSignature(t *testing.T) {
+	s, err := NewWebhookServer(WebhookConfig{
+		Path:         "/webhook",
+		SharedSecret: "s3cret",
+		Router:       &Router{},
+	})
+	if err != nil {
+		t.Fatalf("NewWebhookServer returned error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"webhookEvent":"jira:issue_updated"}`))
+	req.Header.Set(signatureHeader, "not-the-right-signature")
+	rec := httptest.NewRecorder()
+
+	s.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d for a bad signature, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestHandleWebhookCreatesSwimlaneOnIssueCreatedWithStoryLabelAlreadySet(t *testing.T) {
+	// jira:issue_created carries no changelog, so the labels it was
+	// created with have to come from issue.fields instead - regression
+	// test for the bug where such issues were silently never reconciled.
+	transport := &routedTransport{}
+	transport.on("xboard/config.json", jsonResponse(http.StatusOK, `{"currentViewConfig": {"swimlanes": []}}`))
+
+	created := false
+	transport.on("swimlanes", func(request *http.Request) (*http.Response, error) {
+		created = true
+		return jsonResponse(http.StatusOK, `{}`)(request)
+	})
+
+	router, err := NewRouter([]RouteConfig{
+		{Labels: []string{"team-a"}, DashboardID: 7, NameTemplate: "{{.Key}} Swimlane", QueryTemplate: "labels = {{.Key}}"},
+	})
+	if err != nil {
+		t.Fatalf("NewRouter returned error: %v", err)
+	}
+
+	s, err := NewWebhookServer(WebhookConfig{
+		Path:          "/webhook",
+		AllowUnsigned: true,
+		Client:        newTestClient(transport),
+		Router:        router,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhookServer returned error: %v", err)
+	}
+
+	body := `{
+		"webhookEvent": "jira:issue_created",
+		"issue": {
+			"key": "ABC-1",
+			"fields": [
+				{"id": "labels", "text": "swimline-story, team-a"},
+				{"id": "summary", "text": "Some summary"}
+			]
+		}
+	}`
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	s.handleWebhook(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !created {
+		t.Fatal("expected an issue_created webhook with the story label already set to create a swimlane")
+	}
+}